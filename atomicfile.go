@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// atomicWriteFile 把 data 写入一个与 path 同目录下的临时文件，再 rename 到
+// path，保证读者（例如 node-exporter 的 textfile collector）永远只能看到
+// 完整写入的文件，不会读到半截内容。
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, ".tmp-"+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// 正常路径下 rename 会移走临时文件；这里的 Remove 只在出错提前返回时生效。
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
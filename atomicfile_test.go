@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicWriteFileCreatesFileWithContentAndPerm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.prom")
+
+	if err := atomicWriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("atomicWriteFile: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "hello\n" {
+		t.Errorf("content = %q, want %q", data, "hello\n")
+	}
+}
+
+func TestAtomicWriteFileReplacesExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.prom")
+
+	if err := atomicWriteFile(path, []byte("first"), 0644); err != nil {
+		t.Fatalf("atomicWriteFile (first): %v", err)
+	}
+	if err := atomicWriteFile(path, []byte("second"), 0644); err != nil {
+		t.Fatalf("atomicWriteFile (second): %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+}
+
+func TestAtomicWriteFileErrorsOnMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing-subdir", "out.prom")
+
+	if err := atomicWriteFile(path, []byte("x"), 0644); err == nil {
+		t.Fatalf("expected an error when the parent directory does not exist")
+	}
+}
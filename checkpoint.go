@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpoint 是某个 service 在一次成功的检测周期后持久化的最小状态，
+// 让进程重启或崩溃后不必从头假设“还没拿过区块”。
+type checkpoint struct {
+	LastBlockNumber   int       `json:"last_block_number"`
+	LastSeenTimestamp time.Time `json:"last_seen_timestamp"`
+	LogFileOffset     int64     `json:"log_file_offset"`
+}
+
+// defaultCheckpointPath 在 serviceConfig 没有显式指定 checkpoint 路径时，
+// 把 checkpoint 文件放在 .prom 文件旁边，命名为 "<service>.checkpoint.json"。
+func defaultCheckpointPath(serviceName string) string {
+	return filepath.Join(filepath.Dir(promFilePath), serviceName+".checkpoint.json")
+}
+
+// loadCheckpoint 从 path 读取 checkpoint；文件不存在时返回 (nil, nil)，
+// 表示这是该 service 第一次启动。
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint 原子地（tmpfile + rename）把 cp 写入 path。
+func saveCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+	return atomicWriteFile(path, data, 0644)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoadCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-quai-stratum.checkpoint.json")
+
+	want := checkpoint{
+		LastBlockNumber:   1512464,
+		LastSeenTimestamp: time.Date(2026, 1, 14, 9, 55, 34, 0, time.UTC),
+		LogFileOffset:     4096,
+	}
+	if err := saveCheckpoint(path, want); err != nil {
+		t.Fatalf("saveCheckpoint: %v", err)
+	}
+
+	got, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if got == nil {
+		t.Fatalf("loadCheckpoint returned nil, want a checkpoint")
+	}
+	if got.LastBlockNumber != want.LastBlockNumber ||
+		!got.LastSeenTimestamp.Equal(want.LastSeenTimestamp) ||
+		got.LogFileOffset != want.LogFileOffset {
+		t.Errorf("loadCheckpoint() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestLoadCheckpointMissingFileReturnsNil(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.json")
+
+	got, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if got != nil {
+		t.Errorf("loadCheckpoint() = %+v, want nil for missing file", *got)
+	}
+}
+
+func TestSaveCheckpointOverwritesPreviousContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-quai-stratum.checkpoint.json")
+
+	if err := saveCheckpoint(path, checkpoint{LastBlockNumber: 1}); err != nil {
+		t.Fatalf("saveCheckpoint (first): %v", err)
+	}
+	if err := saveCheckpoint(path, checkpoint{LastBlockNumber: 2}); err != nil {
+		t.Fatalf("saveCheckpoint (second): %v", err)
+	}
+
+	got, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+	if got.LastBlockNumber != 2 {
+		t.Errorf("LastBlockNumber = %d, want 2", got.LastBlockNumber)
+	}
+
+	// atomicWriteFile 不应该在目标目录里留下临时文件。
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("checkpoint dir has %d entries, want exactly 1 (no leftover tmp files): %v", len(entries), entries)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceConfig 描述一个要监控的 supervisor service（或任意带时间戳日志的
+// 进程）：日志来源、解析日志行的正则、检测周期，以及该 service 指标使用的
+// 前缀。
+//
+// MetricPrefix 只影响 writePromMetrics 写出的 textfile collector 输出
+// （metrics.go 里的 `{prefix}_continuity{service="..."}`）；通过 -listen
+// 暴露的原生 Prometheus 指标（promexporter.go）固定使用 `quai_stratum_*`
+// 名字，service 之间只靠 `service` label 区分，不读取这个字段。
+type serviceConfig struct {
+	Name           string   `yaml:"name" json:"name"`
+	Log            string   `yaml:"log" json:"log"`
+	Regex          string   `yaml:"regex" json:"regex"`
+	Interval       duration `yaml:"interval" json:"interval"`
+	MetricPrefix   string   `yaml:"metric_prefix" json:"metric_prefix"`
+	CheckpointPath string   `yaml:"checkpoint" json:"checkpoint"`
+}
+
+// monitorConfig 是配置文件的顶层结构。
+type monitorConfig struct {
+	Services []serviceConfig `yaml:"services" json:"services"`
+}
+
+// duration 包装 time.Duration，使其可以从 "60s" 这样的字符串反序列化，
+// 同时也兼容纯数字（纳秒）写法。
+type duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML 支持 "60s" 这样的字符串写法，也兼容纯数字（纳秒）写法。
+// 注意：yaml.Node 对标量节点 Decode(&string) 总是成功（YAML 标量本来就是
+// 文本），所以不能像 UnmarshalJSON 那样靠“先试 string 再试 int64 哪个不报
+// 错”来判断写法，必须先拿到字符串，再分别尝试 ParseDuration 和整数解析。
+func (d *duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("decode duration: %w", err)
+	}
+
+	if parsed, err := time.ParseDuration(s); err == nil {
+		d.Duration = parsed
+		return nil
+	}
+
+	ns, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse duration %q: %w", s, err)
+	}
+	d.Duration = time.Duration(ns)
+	return nil
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil && s != "" {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parse duration %q: %w", s, err)
+		}
+		d.Duration = parsed
+		return nil
+	}
+
+	var ns int64
+	if err := json.Unmarshal(b, &ns); err != nil {
+		return fmt.Errorf("decode duration: %w", err)
+	}
+	d.Duration = time.Duration(ns)
+	return nil
+}
+
+// loadConfig 从 path 读取一个 YAML 或 JSON 格式的 monitorConfig（按扩展名
+// `.json` 区分，其余一律按 YAML 解析），并为每个 service 补上默认值。
+func loadConfig(path string) (*monitorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+
+	var cfg monitorConfig
+	if filepath.Ext(path) == ".json" {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse json config %s: %w", path, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parse yaml config %s: %w", path, err)
+		}
+	}
+
+	if len(cfg.Services) == 0 {
+		return nil, fmt.Errorf("config %s declares no services", path)
+	}
+	for i := range cfg.Services {
+		applyServiceDefaults(&cfg.Services[i])
+	}
+
+	return &cfg, nil
+}
+
+// applyServiceDefaults 给未显式设置的字段填上和旧版本行为一致的默认值。
+func applyServiceDefaults(s *serviceConfig) {
+	if s.Interval.Duration <= 0 {
+		s.Interval.Duration = checkInterval
+	}
+	if s.Regex == "" {
+		s.Regex = defaultLogPattern
+	}
+	if s.MetricPrefix == "" {
+		s.MetricPrefix = defaultMetricPrefix
+	}
+	if s.CheckpointPath == "" {
+		s.CheckpointPath = defaultCheckpointPath(s.Name)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDurationUnmarshalYAML(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want time.Duration
+	}{
+		{"string form", "interval: 90s\n", 90 * time.Second},
+		{"numeric nanoseconds", "interval: 1000000000\n", time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var cfg struct {
+				Interval duration `yaml:"interval"`
+			}
+			if err := yaml.Unmarshal([]byte(c.doc), &cfg); err != nil {
+				t.Fatalf("yaml.Unmarshal: %v", err)
+			}
+			if cfg.Interval.Duration != c.want {
+				t.Errorf("got %v, want %v", cfg.Interval.Duration, c.want)
+			}
+		})
+	}
+}
+
+func TestDurationUnmarshalYAMLInvalidString(t *testing.T) {
+	var cfg struct {
+		Interval duration `yaml:"interval"`
+	}
+	if err := yaml.Unmarshal([]byte("interval: not-a-duration\n"), &cfg); err == nil {
+		t.Fatalf("expected error for invalid duration string, got nil")
+	}
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want time.Duration
+	}{
+		{"string form", `{"interval":"90s"}`, 90 * time.Second},
+		{"numeric nanoseconds", `{"interval":1000000000}`, time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var cfg struct {
+				Interval duration `json:"interval"`
+			}
+			if err := json.Unmarshal([]byte(c.doc), &cfg); err != nil {
+				t.Fatalf("json.Unmarshal: %v", err)
+			}
+			if cfg.Interval.Duration != c.want {
+				t.Errorf("got %v, want %v", cfg.Interval.Duration, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyServiceDefaults(t *testing.T) {
+	svc := serviceConfig{Name: "go-quai-stratum"}
+	applyServiceDefaults(&svc)
+
+	if svc.Interval.Duration != checkInterval {
+		t.Errorf("Interval = %v, want %v", svc.Interval.Duration, checkInterval)
+	}
+	if svc.Regex != defaultLogPattern {
+		t.Errorf("Regex = %q, want %q", svc.Regex, defaultLogPattern)
+	}
+	if svc.MetricPrefix != defaultMetricPrefix {
+		t.Errorf("MetricPrefix = %q, want %q", svc.MetricPrefix, defaultMetricPrefix)
+	}
+	if svc.CheckpointPath != defaultCheckpointPath("go-quai-stratum") {
+		t.Errorf("CheckpointPath = %q, want %q", svc.CheckpointPath, defaultCheckpointPath("go-quai-stratum"))
+	}
+}
+
+func TestApplyServiceDefaultsDoesNotOverrideExplicitValues(t *testing.T) {
+	svc := serviceConfig{
+		Name:         "custom",
+		Interval:     duration{5 * time.Second},
+		Regex:        "custom-regex",
+		MetricPrefix: "custom_prefix",
+	}
+	applyServiceDefaults(&svc)
+
+	if svc.Interval.Duration != 5*time.Second {
+		t.Errorf("Interval was overridden: got %v", svc.Interval.Duration)
+	}
+	if svc.Regex != "custom-regex" {
+		t.Errorf("Regex was overridden: got %q", svc.Regex)
+	}
+	if svc.MetricPrefix != "custom_prefix" {
+		t.Errorf("MetricPrefix was overridden: got %q", svc.MetricPrefix)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pollInterval 控制 logTailer 轮询文件新增内容的频率。
+//
+// 注意：目前只实现了轮询，没有 fsnotify/inotify 的事件驱动路径——tailFile
+// 的循环在每次读到 EOF 后都无条件 sleep(pollInterval) 再重试。200ms 的延迟
+// 对这个监控场景可以接受，就没有为此引入额外的依赖。
+const pollInterval = 200 * time.Millisecond
+
+// reopenBackoff 是 tailer 在日志文件/子进程出错后重试之前等待的时间。
+const reopenBackoff = 2 * time.Second
+
+// blockRecord 是从一行日志中解析出来的一次区块广播事件。
+type blockRecord struct {
+	Timestamp   time.Time
+	BlockNumber int
+	Miners      int
+}
+
+// ringBuffer 按时间顺序保存最近一段时间内的 blockRecord，替代原来每分钟
+// 重新执行 `supervisorctl tail` 读取固定行数日志的做法。记录按 Timestamp
+// 单调追加，查询时只需从头扫描即可找到窗口起点。
+type ringBuffer struct {
+	mu      sync.Mutex
+	records []blockRecord
+	// retain 是 ringBuffer 为每条记录保留的最长时间，超出这个时间的记录
+	// 会在下一次 push 时被裁剪掉，避免无限增长。
+	retain time.Duration
+}
+
+// newRingBuffer 创建一个保留时长为 retain 的 ringBuffer。
+func newRingBuffer(retain time.Duration) *ringBuffer {
+	return &ringBuffer{retain: retain}
+}
+
+// push 追加一条记录，并裁剪掉早于 retain 窗口之前的历史记录。
+func (rb *ringBuffer) push(r blockRecord) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.records = append(rb.records, r)
+
+	cutoff := r.Timestamp.Add(-rb.retain)
+	i := 0
+	for i < len(rb.records) && rb.records[i].Timestamp.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		rb.records = append([]blockRecord(nil), rb.records[i:]...)
+	}
+}
+
+// since 返回时间戳晚于 afterTime 的所有记录，按到达顺序排列。
+func (rb *ringBuffer) since(afterTime time.Time) []blockRecord {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	out := make([]blockRecord, 0, len(rb.records))
+	for _, r := range rb.records {
+		if r.Timestamp.After(afterTime) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// logTailer 是一个长期运行的日志尾随器：优先直接读取 logPath 指向的文件，
+// 启动时 seek 到文件末尾，之后只消费新增的字节；如果 logPath 为空或打不开，
+// 回退到 `supervisorctl tail -f serviceName`，同样是一次性启动、持续消费，
+// 而不是旧版本那样每个 checkInterval 重新拉起一次子进程。
+type logTailer struct {
+	serviceName string
+	logPath     string
+	buffer      *ringBuffer
+	lineRegex   *regexp.Regexp
+
+	// onRecord 在每条记录被推入 buffer 之后调用，可以为 nil。用于让
+	// promExporter 这样的旁路消费者在不侵入 ring buffer 的情况下观察
+	// 到每一条解析出来的 blockRecord。
+	onRecord func(blockRecord)
+
+	// startOffset 是从 checkpoint 恢复时应该从哪个字节偏移量继续读取
+	// logPath，0 表示没有 checkpoint 可恢复，直接从 EOF 开始 tail。只在
+	// 进程启动后第一次打开文件时生效，之后的重新打开（日志轮转）一律从
+	// EOF 继续。
+	startOffset        int64
+	appliedStartOffset bool
+	currentOffset      atomic.Int64
+}
+
+// newLogTailer 构造一个尾随指定 service 日志的 logTailer。logPath 为空时
+// 总是走 supervisorctl 回退路径。lineRegex 必须包含 (时间戳, 区块号, 矿工数)
+// 三个捕获组，与 parseLogLine 的约定一致。
+func newLogTailer(serviceName, logPath string, buffer *ringBuffer, lineRegex *regexp.Regexp) *logTailer {
+	return &logTailer{
+		serviceName: serviceName,
+		logPath:     logPath,
+		buffer:      buffer,
+		lineRegex:   lineRegex,
+	}
+}
+
+// Offset 返回当前已经读取到的文件字节偏移量，用于持久化到 checkpoint。
+// 走 supervisorctl 回退路径时恒为 0。
+func (lt *logTailer) Offset() int64 {
+	return lt.currentOffset.Load()
+}
+
+// run 永久阻塞，直到进程退出；调用方应当用 `go tailer.run()` 启动。
+// 任何一次 tail 尝试失败后都会退避重试，不会让整个监控进程崩溃。
+func (lt *logTailer) run() {
+	for {
+		var err error
+		if lt.logPath != "" {
+			err = lt.tailFile(lt.logPath)
+		} else {
+			err = lt.tailSupervisorFollow()
+		}
+		if err != nil {
+			fmt.Printf("[WARN] logTailer: %v, retrying in %s\n", err, reopenBackoff)
+		}
+		time.Sleep(reopenBackoff)
+	}
+}
+
+// tailFile 打开 path，seek 到 EOF，然后轮询式地读取新增行，直到遇到不可恢复的错误。
+// 轮转检测覆盖两种情况：原地截断（文件变小）和 logrotate 式的 rename+create
+// （inode 变了，但被我们这个 fd 打开的旧文件大小不会减小，必须靠 os.SameFile
+// 比较 path 当前指向的文件和已打开的 fd 是否还是同一个）。
+func (lt *logTailer) tailFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	// 只有进程启动后第一次打开文件时才尝试从 checkpoint 恢复的偏移量继续
+	// 读取；后续因为轮转重新打开的文件一律从 EOF 开始。
+	resumeOffset := int64(0)
+	if !lt.appliedStartOffset {
+		resumeOffset = lt.startOffset
+	}
+	lt.appliedStartOffset = true
+
+	var startPos int64
+	if resumeOffset > 0 {
+		startPos, err = f.Seek(resumeOffset, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("seek %s to checkpoint offset %d: %w", path, resumeOffset, err)
+		}
+	} else {
+		startPos, err = f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("seek %s: %w", path, err)
+		}
+	}
+	pos := startPos
+	lt.currentOffset.Store(pos)
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			lt.ingest(line)
+			pos += int64(len(line))
+			lt.currentOffset.Store(pos)
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+
+		// 检测日志轮转，两种情况都要重新打开：
+		//  1) 原地截断：当前 fd 的大小比已读偏移量还小。
+		//  2) rename+create（常见的 logrotate 做法）：path 现在指向一个新文件，
+		//     和我们这个 fd 打开的旧文件不再是同一个 inode；这种情况下旧文件的
+		//     大小不会变小，必须额外对比 os.SameFile。
+		info, statErr := f.Stat()
+		if statErr == nil && info.Size() < lt.currentOffset.Load() {
+			return fmt.Errorf("%s truncated, reopening", path)
+		}
+		if statErr == nil {
+			if pathInfo, err := os.Stat(path); err == nil && !os.SameFile(info, pathInfo) {
+				return fmt.Errorf("%s rotated to a new file, reopening", path)
+			}
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// tailSupervisorFollow 启动 `supervisorctl tail -f serviceName` 并持续消费其
+// stdout，直到子进程退出。这是没有可直接读取的日志文件路径时的回退方案。
+func (lt *logTailer) tailSupervisorFollow() error {
+	cmd := exec.Command("supervisorctl", "tail", "-f", lt.serviceName)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("cmd.StdoutPipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cmd.Start: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		lt.ingest(scanner.Text() + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		_ = cmd.Wait()
+		return fmt.Errorf("scanner.Err: %w", err)
+	}
+
+	return cmd.Wait()
+}
+
+// ingest 解析一行日志，命中正则的才会被推入 ring buffer。
+func (lt *logTailer) ingest(line string) {
+	t, blockNum, miners, ok := parseLogLine(line, lt.lineRegex)
+	if !ok {
+		return
+	}
+	record := blockRecord{Timestamp: t, BlockNumber: blockNum, Miners: miners}
+	lt.buffer.push(record)
+	if lt.onRecord != nil {
+		lt.onRecord(record)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRingBufferSinceReturnsOnlyNewerRecords(t *testing.T) {
+	rb := newRingBuffer(time.Hour)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rb.push(blockRecord{Timestamp: base, BlockNumber: 1})
+	rb.push(blockRecord{Timestamp: base.Add(time.Minute), BlockNumber: 2})
+	rb.push(blockRecord{Timestamp: base.Add(2 * time.Minute), BlockNumber: 3})
+
+	got := rb.since(base.Add(30 * time.Second))
+	if len(got) != 2 {
+		t.Fatalf("since() returned %d records, want 2: %v", len(got), got)
+	}
+	if got[0].BlockNumber != 2 || got[1].BlockNumber != 3 {
+		t.Fatalf("since() = %v, want blocks [2 3]", got)
+	}
+}
+
+func TestRingBufferPushTrimsOlderThanRetain(t *testing.T) {
+	rb := newRingBuffer(time.Minute)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rb.push(blockRecord{Timestamp: base, BlockNumber: 1})
+	// 晚了两分钟到达，超过了 1 分钟的 retain 窗口，应该把第一条记录裁剪掉。
+	rb.push(blockRecord{Timestamp: base.Add(2 * time.Minute), BlockNumber: 2})
+
+	got := rb.since(time.Time{})
+	if len(got) != 1 || got[0].BlockNumber != 2 {
+		t.Fatalf("push() did not trim stale records, got %v", got)
+	}
+}
+
+func TestRingBufferSinceOnEmptyBuffer(t *testing.T) {
+	rb := newRingBuffer(time.Minute)
+	if got := rb.since(time.Now()); len(got) != 0 {
+		t.Fatalf("since() on empty buffer = %v, want empty", got)
+	}
+}
@@ -1,164 +1,275 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
-	"os"
-	"os/exec"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-// Prometheus 指标文件输出路径
+// Prometheus 指标文件输出路径（默认模式下使用，config 模式下同样作为
+// 所有 service 共用的聚合输出文件）。
 const promFilePath = "/opt/node-exporter/prom/go-quai-stratum.prom"
 
 // 每次检测的间隔
 const checkInterval = time.Minute
 
-// supervisorctl tail --lines=XXX 读取多少行日志，用于截取“最近”日志
-// 具体行数可以根据你的日志产生速率适度调整
-const tailLines = "300"
+// go-quai-stratum 日志文件路径。留空时回退到 `supervisorctl tail -f`。
+const logFilePath = "/var/log/go-quai-stratum/stdout.log"
+
+// ringBufferRetainFactor 是 ring buffer 相对于 service 自身 checkInterval
+// 保留的倍数，需要大于 1，给窗口查询留出余量。
+const ringBufferRetainFactor = 5
 
-// 正则示例：
-//  2025/01/14 09:55:34 Broadcasting block 1512464 to 405 stratum miners
-//  ^(2025/01/14 09:55:34) Broadcasting block (1512464) to (405) stratum miners
+// defaultLogPattern 是未在 config 中指定 regex 时使用的默认正则，例如：
+//
+//	2025/01/14 09:55:34 Broadcasting block 1512464 to 405 stratum miners
+//	^(2025/01/14 09:55:34) Broadcasting block (1512464) to (405) stratum miners
+//
 // 时间格式：2006/01/02 15:04:05
-var logRegex = regexp.MustCompile(
-	`^(\d{4}\/\d{2}\/\d{2}\s\d{2}:\d{2}:\d{2})\s+Broadcasting block (\d+) to (\d+) stratum miners`,
-)
+const defaultLogPattern = `^(\d{4}\/\d{2}\/\d{2}\s\d{2}:\d{2}:\d{2})\s+Broadcasting block (\d+) to (\d+) stratum miners`
 
-// 维护一个上次记录的最高区块，用于判断是否“更新”
-var lastBlockNumber int
+// defaultMetricPrefix 是未在 config 中指定 metric_prefix 时使用的前缀，
+// 沿用旧版固定的 `quai_stratum_block_number` 指标名前缀。
+const defaultMetricPrefix = "quai_stratum_block_number"
 
 func main() {
-	// 第一次跑可以把 lastBlockNumber 设置成 -1 或 0，表示还没拿过区块
-	lastBlockNumber = -1
+	configPath := flag.String("config", "", "path to a YAML/JSON config describing multiple services; when empty, monitors the single go-quai-stratum service using the legacy built-in defaults")
+	listenAddr := flag.String("listen", "", "if set, serve Prometheus metrics natively on this address (e.g. :9183) instead of relying solely on the textfile collector output")
+	noTextfile := flag.Bool("no-textfile", false, "disable writing the node-exporter textfile collector .prom file; only useful together with -listen")
+	flag.Parse()
 
-	for {
-		// 每次循环的起始时间
-		startTime := time.Now()
+	if *noTextfile && *listenAddr == "" {
+		fmt.Printf("[ERROR] -no-textfile requires -listen, otherwise no metrics would be exported at all\n")
+		return
+	}
 
-		// 读取“过去一分钟”内的所有区块高度
-		blocks, err := readRecentBlocks("go-quai-stratum", startTime.Add(-checkInterval))
+	var cfg *monitorConfig
+	if *configPath != "" {
+		loaded, err := loadConfig(*configPath)
 		if err != nil {
-			fmt.Printf("[ERROR] readRecentBlocks: %v\n", err)
-			// 即使报错，也要等下一个周期重试
-			sleepUntilNext(startTime)
-			continue
+			fmt.Printf("[ERROR] loadConfig: %v\n", err)
+			return
+		}
+		cfg = loaded
+	} else {
+		cfg = defaultConfig()
+	}
+
+	var exporter *promExporter
+	if *listenAddr != "" {
+		warnIfMetricPrefixesDiverge(cfg)
+
+		promRegistry := prometheus.NewRegistry()
+		exporter = newPromExporter(promRegistry)
+		go func() {
+			if err := serveMetrics(*listenAddr, promRegistry); err != nil {
+				fmt.Printf("[ERROR] serveMetrics: %v\n", err)
+			}
+		}()
+	}
+
+	var registry *metricsRegistry
+	if !*noTextfile {
+		registry = newMetricsRegistry(promFilePath)
+	}
+	for _, svc := range cfg.Services {
+		go runService(svc, registry, exporter)
+	}
+
+	// 主 goroutine 只负责保活，真正的工作都在各个 runService 里进行。
+	select {}
+}
+
+// warnIfMetricPrefixesDiverge 在 -listen 模式下提醒一次：每个 service 在
+// config 里声明的 metric_prefix 只会体现在 textfile collector 输出里，
+// 原生 Prometheus 指标固定使用 quai_stratum_* 名字，不会按 prefix 区分。
+func warnIfMetricPrefixesDiverge(cfg *monitorConfig) {
+	seen := make(map[string]bool)
+	prefixes := make([]string, 0, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		if !seen[svc.MetricPrefix] {
+			seen[svc.MetricPrefix] = true
+			prefixes = append(prefixes, svc.MetricPrefix)
+		}
+	}
+	if len(prefixes) > 1 {
+		fmt.Printf("[WARN] multiple distinct metric_prefix values configured (%v), but the -listen exporter always uses quai_stratum_* metric names and only distinguishes services via the \"service\" label\n", prefixes)
+	}
+}
+
+// defaultConfig 还原旧版本硬编码的单 service 行为：只监控
+// go-quai-stratum，使用包级别的 logFilePath/checkInterval/defaultLogPattern。
+func defaultConfig() *monitorConfig {
+	return &monitorConfig{
+		Services: []serviceConfig{
+			{
+				Name:           "go-quai-stratum",
+				Log:            logFilePath,
+				Regex:          defaultLogPattern,
+				Interval:       duration{checkInterval},
+				MetricPrefix:   defaultMetricPrefix,
+				CheckpointPath: defaultCheckpointPath("go-quai-stratum"),
+			},
+		},
+	}
+}
+
+// runService 为单个 service 运行一个完整的 tail -> 判断 -> 上报指标循环，
+// 永久阻塞，调用方应以 `go runService(...)` 启动。每个 service 维护自己独立
+// 的 lastBlockNumber，彼此互不影响。
+func runService(svc serviceConfig, registry *metricsRegistry, exporter *promExporter) {
+	lineRegex, err := regexp.Compile(svc.Regex)
+	if err != nil {
+		fmt.Printf("[ERROR] service %s: invalid regex %q: %v\n", svc.Name, svc.Regex, err)
+		return
+	}
+
+	interval := svc.Interval.Duration
+	buffer := newRingBuffer(interval * ringBufferRetainFactor)
+	tailer := newLogTailer(svc.Name, svc.Log, buffer, lineRegex)
+
+	// 从 checkpoint 恢复状态，避免每次重启都把 lastBlockNumber 清零成 -1。
+	lastBlockNumber := -1
+	forceNotUpdatedFirstCycle := false
+	prevCheckpoint, err := loadCheckpoint(svc.CheckpointPath)
+	if err != nil {
+		fmt.Printf("[WARN] service %s: loadCheckpoint: %v\n", svc.Name, err)
+	} else if prevCheckpoint != nil {
+		lastBlockNumber = prevCheckpoint.LastBlockNumber
+		tailer.startOffset = prevCheckpoint.LogFileOffset
+		if time.Since(prevCheckpoint.LastSeenTimestamp) > interval {
+			forceNotUpdatedFirstCycle = true
+		}
+	}
+
+	stall := newStallTracker(interval * stallMultiplier)
+	var prevTimestamp time.Time
+	tailer.onRecord = func(r blockRecord) {
+		stall.observe(r.Timestamp, time.Now())
+		if exporter != nil {
+			exporter.observeRecord(svc.Name, r, prevTimestamp)
 		}
+		prevTimestamp = r.Timestamp
+	}
+	go tailer.run()
+
+	firstCycle := true
+
+	for {
+		startTime := time.Now()
+
+		blocks := recentBlockNumbers(buffer, startTime.Add(-interval))
 
-		// 连续性检查
 		isContinuous := checkContinuity(blocks)
 
-		// 是否更新检查
 		currentBlockNumber := getLatestBlock(blocks)
 		isUpdated := 1
 		if currentBlockNumber == lastBlockNumber {
 			isUpdated = 0
 		} else if currentBlockNumber > 0 {
-			// 当拿到有效的最新区块时才更新全局记录
 			lastBlockNumber = currentBlockNumber
 		}
+		if firstCycle && forceNotUpdatedFirstCycle && currentBlockNumber <= 0 {
+			// checkpoint 里的 lastSeenTimestamp 已经比一个 interval 还旧，且
+			// 本轮窗口里也没有观测到任何新区块，说明恢复前服务大概率已经
+			// 停止更新，不应该仅仅因为 lastBlockNumber 是刚恢复的而误报成
+			// "已更新"。
+			isUpdated = 0
+		}
+		firstCycle = false
 
-		// 写入 Prom 文件
-		if err := writePromMetrics(isContinuous, isUpdated); err != nil {
-			fmt.Printf("[ERROR] writePromMetrics: %v\n", err)
+		if registry != nil {
+			if err := registry.update(serviceMetricsSnapshot{
+				Name:       svc.Name,
+				Prefix:     svc.MetricPrefix,
+				Continuity: isContinuous,
+				Updated:    isUpdated,
+			}); err != nil {
+				fmt.Printf("[ERROR] service %s: writePromMetrics: %v\n", svc.Name, err)
+			}
+		}
+		secondsSinceLastBlock, stalled, clockSkewSeconds := stall.snapshot(time.Now())
+		if exporter != nil {
+			exporter.setCycleResult(svc.Name, isContinuous, isUpdated)
+			exporter.setStallAndSkew(svc.Name, secondsSinceLastBlock, stalled, clockSkewSeconds)
+		}
+		if stalled {
+			fmt.Printf("[WARN] service %s: stalled, %.1fs since last broadcast block\n", svc.Name, secondsSinceLastBlock)
+		}
+
+		lastSeenTimestamp := stall.LastSeen()
+		if lastSeenTimestamp.IsZero() && prevCheckpoint != nil {
+			lastSeenTimestamp = prevCheckpoint.LastSeenTimestamp
+		}
+		if err := saveCheckpoint(svc.CheckpointPath, checkpoint{
+			LastBlockNumber:   lastBlockNumber,
+			LastSeenTimestamp: lastSeenTimestamp,
+			LogFileOffset:     tailer.Offset(),
+		}); err != nil {
+			fmt.Printf("[ERROR] service %s: saveCheckpoint: %v\n", svc.Name, err)
 		}
 
-		// 打印一些调试日志，便于观察
 		fmt.Printf(
-			"[DEBUG] time=%s, foundBlocks=%d, blocks=%v, continuity=%d, updated=%d, lastBlockNumber=%d\n",
+			"[DEBUG] service=%s, time=%s, foundBlocks=%d, blocks=%v, continuity=%d, updated=%d, lastBlockNumber=%d, secondsSinceLastBlock=%.1f, clockSkewSeconds=%.3f\n",
+			svc.Name,
 			time.Now().Format("2006-01-02 15:04:05"),
 			len(blocks),
 			blocks,
 			isContinuous,
 			isUpdated,
 			lastBlockNumber,
+			secondsSinceLastBlock,
+			clockSkewSeconds,
 		)
 
-		// 等待下一次循环
-		sleepUntilNext(startTime)
+		sleepUntilNext(startTime, interval)
 	}
 }
 
-// readRecentBlocks 调用 `supervisorctl tail <serviceName> --lines=300` 读取最近若干行日志，
-// 并从中筛选出在 afterTime 之后的日志行，返回解析得到的 block 列表。
-func readRecentBlocks(serviceName string, afterTime time.Time) ([]int, error) {
-	// 调 supervisorctl:  tail --lines=300 <serviceName>
-	cmd := exec.Command("supervisorctl", "tail", serviceName, "--lines="+tailLines)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("cmd.StdoutPipe: %w", err)
+// recentBlockNumbers 从 ring buffer 中查询 afterTime 之后的所有记录，
+// 按到达顺序返回区块高度列表，供 checkContinuity/getLatestBlock 使用。
+func recentBlockNumbers(buffer *ringBuffer, afterTime time.Time) []int {
+	records := buffer.since(afterTime)
+	blocks := make([]int, 0, len(records))
+	for _, r := range records {
+		blocks = append(blocks, r.BlockNumber)
 	}
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("cmd.Start: %w", err)
-	}
-
-	lines := make([]string, 0)
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scanner.Err: %w", err)
-	}
-
-	// 等待子进程退出
-	if err := cmd.Wait(); err != nil {
-		// supervisorctl tail 命令可能返回状态码非0，不一定就是错误
-		// 这里简单记录一下
-		fmt.Printf("[WARN] supervisorctl tail exit: %v\n", err)
-	}
-
-	fmt.Printf("[DEBUG] readRecentBlocks: total lines read=%d\n", len(lines))
-
-	// 从这些行里，提取在 afterTime 之后的 block
-	blocks := make([]int, 0)
-	for _, line := range lines {
-		t, blockNum, ok := parseTimeAndBlock(line)
-		if !ok {
-			// 不符合正则或解析失败就跳过
-			continue
-		}
-		if t.After(afterTime) {
-			blocks = append(blocks, blockNum)
-		}
-	}
-
-	fmt.Printf("[DEBUG] readRecentBlocks: lines after %s => blocks=%v\n",
-		afterTime.Format("15:04:05"),
-		blocks,
-	)
-
-	return blocks, nil
+	return blocks
 }
 
-// parseTimeAndBlock 从一行形如
-//   "2025/01/14 09:55:34 Broadcasting block 1512464 to 405 stratum miners"
-// 中解析出 (time, blockNumber)。若解析成功返回 (t, blockNum, true)，否则 (零值, 0, false)。
-func parseTimeAndBlock(line string) (time.Time, int, bool) {
-	matches := logRegex.FindStringSubmatch(line)
+// parseLogLine 用 re 从一行日志中解析出 (time, blockNumber, miners)。re 必须
+// 包含 (时间戳, 区块号, 矿工数) 三个捕获组，例如：
+//
+//	"2025/01/14 09:55:34 Broadcasting block 1512464 to 405 stratum miners"
+//
+// 若解析成功返回 (t, blockNum, miners, true)，否则 (零值, 0, 0, false)。
+func parseLogLine(line string, re *regexp.Regexp) (time.Time, int, int, bool) {
+	matches := re.FindStringSubmatch(line)
 	if len(matches) != 4 {
-		return time.Time{}, 0, false
+		return time.Time{}, 0, 0, false
 	}
-	// 解析时间
-	tStr := matches[1] // "2025/01/14 09:55:34"
-	blockStr := matches[2] // "1512464"
-	// minersStr := matches[3] // "405" (如果需要，可以留着)
+	tStr := matches[1]      // "2025/01/14 09:55:34"
+	blockStr := matches[2]  // "1512464"
+	minersStr := matches[3] // "405"
 
 	t, err := time.Parse("2006/01/02 15:04:05", tStr)
 	if err != nil {
-		return time.Time{}, 0, false
+		return time.Time{}, 0, 0, false
 	}
 	blockNum, err := strconv.Atoi(blockStr)
 	if err != nil {
-		return time.Time{}, 0, false
+		return time.Time{}, 0, 0, false
+	}
+	miners, err := strconv.Atoi(minersStr)
+	if err != nil {
+		return time.Time{}, 0, 0, false
 	}
 
-	return t, blockNum, true
+	return t, blockNum, miners, true
 }
 
 // checkContinuity 判断 blocks 是否严格连续
@@ -183,21 +294,9 @@ func getLatestBlock(blocks []int) int {
 	return blocks[len(blocks)-1]
 }
 
-// writePromMetrics 将两个指标写入 promFilePath
-func writePromMetrics(isContinuous, isUpdated int) error {
-	content := strings.Join([]string{
-		fmt.Sprintf("quai_stratum_block_number_continuity %d", isContinuous),
-		fmt.Sprintf("quai_stratum_block_number_update %d", isUpdated),
-		"",
-	}, "\n")
-
-	// 若 /opt/node-exporter/prom 目录不存在，需要手动创建并赋予权限
-	return os.WriteFile(promFilePath, []byte(content), 0644)
-}
-
-// sleepUntilNext 让程序休眠到下个 checkInterval 周期
-func sleepUntilNext(start time.Time) {
-	sleepDuration := checkInterval - time.Since(start)
+// sleepUntilNext 让程序休眠到下个 interval 周期
+func sleepUntilNext(start time.Time, interval time.Duration) {
+	sleepDuration := interval - time.Since(start)
 	if sleepDuration > 0 {
 		time.Sleep(sleepDuration)
 	}
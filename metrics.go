@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// serviceMetricsSnapshot 是某个 service 在某一轮检测后的指标快照。
+type serviceMetricsSnapshot struct {
+	Name       string
+	Prefix     string
+	Continuity int
+	Updated    int
+}
+
+// metricsRegistry 汇总所有 service 的最新 serviceMetricsSnapshot，并在每次
+// 更新时把全量内容原子地写入同一个 .prom 文件，供 node-exporter 的
+// textfile collector 抓取。
+type metricsRegistry struct {
+	mu        sync.Mutex
+	snapshots map[string]serviceMetricsSnapshot
+	path      string
+}
+
+// newMetricsRegistry 创建一个把聚合指标写入 path 的 metricsRegistry。
+func newMetricsRegistry(path string) *metricsRegistry {
+	return &metricsRegistry{
+		snapshots: make(map[string]serviceMetricsSnapshot),
+		path:      path,
+	}
+}
+
+// update 记录 s 的最新快照，并重新写出完整的 .prom 文件。
+func (r *metricsRegistry) update(s serviceMetricsSnapshot) error {
+	r.mu.Lock()
+	r.snapshots[s.Name] = s
+	content := renderPromMetrics(r.snapshots)
+	r.mu.Unlock()
+
+	return atomicWriteFile(r.path, []byte(content), 0644)
+}
+
+// renderPromMetrics 把 snapshots 渲染成 Prometheus 文本格式，每个 service
+// 各自的 metric_prefix 加上 `service` label 区分，例如：
+//
+//	quai_stratum_block_number_continuity{service="go-quai-stratum"} 1
+//	quai_stratum_block_number_update{service="go-quai-stratum"} 1
+func renderPromMetrics(snapshots map[string]serviceMetricsSnapshot) string {
+	names := make([]string, 0, len(snapshots))
+	for name := range snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		s := snapshots[name]
+		fmt.Fprintf(&sb, "%s_continuity{service=%q} %d\n", s.Prefix, s.Name, s.Continuity)
+		fmt.Fprintf(&sb, "%s_update{service=%q} %d\n", s.Prefix, s.Name, s.Updated)
+	}
+	return sb.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPromMetricsFormatsPerServiceLinesWithPrefix(t *testing.T) {
+	snapshots := map[string]serviceMetricsSnapshot{
+		"go-quai-stratum": {Name: "go-quai-stratum", Prefix: "quai_stratum", Continuity: 1, Updated: 0},
+	}
+
+	got := renderPromMetrics(snapshots)
+	want := "quai_stratum_continuity{service=\"go-quai-stratum\"} 1\n" +
+		"quai_stratum_update{service=\"go-quai-stratum\"} 0\n"
+	if got != want {
+		t.Errorf("renderPromMetrics() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderPromMetricsUsesPerServicePrefixAndIsSortedByName(t *testing.T) {
+	snapshots := map[string]serviceMetricsSnapshot{
+		"svc-b": {Name: "svc-b", Prefix: "other_prefix", Continuity: 0, Updated: 1},
+		"svc-a": {Name: "svc-a", Prefix: "quai_stratum", Continuity: 1, Updated: 1},
+	}
+
+	got := renderPromMetrics(snapshots)
+	wantOrder := []string{
+		`quai_stratum_continuity{service="svc-a"} 1`,
+		`quai_stratum_update{service="svc-a"} 1`,
+		`other_prefix_continuity{service="svc-b"} 0`,
+		`other_prefix_update{service="svc-b"} 1`,
+	}
+	lines := strings.Split(strings.TrimRight(got, "\n"), "\n")
+	if len(lines) != len(wantOrder) {
+		t.Fatalf("renderPromMetrics() produced %d lines, want %d: %q", len(lines), len(wantOrder), got)
+	}
+	for i, want := range wantOrder {
+		if lines[i] != want {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestMetricsRegistryUpdateRetainsPreviousServices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quai-stratum.prom")
+	r := newMetricsRegistry(path)
+
+	if err := r.update(serviceMetricsSnapshot{Name: "svc-a", Prefix: "quai_stratum", Continuity: 1, Updated: 1}); err != nil {
+		t.Fatalf("update (svc-a): %v", err)
+	}
+	if err := r.update(serviceMetricsSnapshot{Name: "svc-b", Prefix: "quai_stratum", Continuity: 0, Updated: 0}); err != nil {
+		t.Fatalf("update (svc-b): %v", err)
+	}
+	// svc-a 的快照应该还在，不会被 svc-b 的 update 覆盖或丢弃。
+	if err := r.update(serviceMetricsSnapshot{Name: "svc-a", Prefix: "quai_stratum", Continuity: 1, Updated: 0}); err != nil {
+		t.Fatalf("update (svc-a again): %v", err)
+	}
+
+	if len(r.snapshots) != 2 {
+		t.Fatalf("snapshots has %d entries, want 2: %v", len(r.snapshots), r.snapshots)
+	}
+	if got := r.snapshots["svc-b"]; got.Continuity != 0 || got.Updated != 0 {
+		t.Errorf("svc-b snapshot corrupted by later update to svc-a: %+v", got)
+	}
+	if got := r.snapshots["svc-a"]; got.Updated != 0 {
+		t.Errorf("svc-a snapshot not updated by latest call: %+v", got)
+	}
+}
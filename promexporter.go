@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// promExporter 把解析出来的区块事件和每轮检测结果注册成原生 Prometheus
+// 指标，通过 /metrics 直接提供给 Prometheus 抓取，不再依赖 node-exporter
+// 的 textfile collector 和中间 .prom 文件。所有指标都带 `service` label，
+// 和 writePromMetrics 的多 service 模型保持一致。
+//
+// 注意：这里的指标名固定为 `quai_stratum_*`，不使用 serviceConfig.MetricPrefix——
+// Prometheus 的 register 是按指标名做的，同一批指标如果每个 service 用不同名字
+// 注册，/metrics 就没法再用一份固定的 collector 集合稳定地表达它们。
+// metric_prefix 目前只影响 writePromMetrics 写出的 textfile collector 输出；
+// 区分不同 service 的原生指标一律靠 `service` label。
+type promExporter struct {
+	continuity            *prometheus.GaugeVec
+	updated               *prometheus.GaugeVec
+	blocksBroadcastTotal  *prometheus.CounterVec
+	latestBlock           *prometheus.GaugeVec
+	blockIntervalSeconds  *prometheus.HistogramVec
+	minersConnected       *prometheus.GaugeVec
+	secondsSinceLastBlock *prometheus.GaugeVec
+	stalled               *prometheus.GaugeVec
+	logClockSkewSeconds   *prometheus.GaugeVec
+}
+
+// newPromExporter 创建并注册一组新的指标到 registry。
+func newPromExporter(registry *prometheus.Registry) *promExporter {
+	e := &promExporter{
+		continuity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_block_number_continuity",
+			Help: "1 if the blocks observed in the last check window were strictly sequential, 0 otherwise.",
+		}, []string{"service"}),
+		updated: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_block_number_update",
+			Help: "1 if the latest block number advanced since the previous check, 0 otherwise.",
+		}, []string{"service"}),
+		blocksBroadcastTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "quai_stratum_blocks_broadcast_total",
+			Help: "Total number of \"Broadcasting block\" log lines observed.",
+		}, []string{"service"}),
+		latestBlock: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_latest_block",
+			Help: "Highest block number observed so far.",
+		}, []string{"service"}),
+		blockIntervalSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "quai_stratum_block_interval_seconds",
+			Help:    "Inter-arrival time between consecutive broadcast blocks.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service"}),
+		minersConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_miners_connected",
+			Help: "Number of stratum miners reported on the most recent broadcast block.",
+		}, []string{"service"}),
+		secondsSinceLastBlock: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_seconds_since_last_block",
+			Help: "Wall-clock seconds since the last \"Broadcasting block\" log line was observed.",
+		}, []string{"service"}),
+		stalled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_stalled",
+			Help: "1 if the gap since the last broadcast block exceeds the stall threshold, 0 otherwise.",
+		}, []string{"service"}),
+		logClockSkewSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "quai_stratum_log_clock_skew_seconds",
+			Help: "Estimated skew between parsed log timestamps and the wall clock at ingest time.",
+		}, []string{"service"}),
+	}
+
+	registry.MustRegister(
+		e.continuity,
+		e.updated,
+		e.blocksBroadcastTotal,
+		e.latestBlock,
+		e.blockIntervalSeconds,
+		e.minersConnected,
+		e.secondsSinceLastBlock,
+		e.stalled,
+		e.logClockSkewSeconds,
+	)
+
+	return e
+}
+
+// observeRecord 在每次从日志里解析出一条新的 blockRecord 时调用，更新计数器、
+// 最新区块号和矿工数；如果 prevTimestamp 非零，还会记录与上一条记录的间隔。
+func (e *promExporter) observeRecord(service string, r blockRecord, prevTimestamp time.Time) {
+	e.blocksBroadcastTotal.WithLabelValues(service).Inc()
+	e.latestBlock.WithLabelValues(service).Set(float64(r.BlockNumber))
+	e.minersConnected.WithLabelValues(service).Set(float64(r.Miners))
+	if !prevTimestamp.IsZero() {
+		e.blockIntervalSeconds.WithLabelValues(service).Observe(r.Timestamp.Sub(prevTimestamp).Seconds())
+	}
+}
+
+// setCycleResult 记录某个 service 一轮检测的 continuity/updated 结果。
+func (e *promExporter) setCycleResult(service string, continuity, updated int) {
+	e.continuity.WithLabelValues(service).Set(float64(continuity))
+	e.updated.WithLabelValues(service).Set(float64(updated))
+}
+
+// setStallAndSkew 记录某个 service 的停滞检测和时钟偏差估计结果。
+func (e *promExporter) setStallAndSkew(service string, secondsSinceLastBlock float64, stalled bool, clockSkewSeconds float64) {
+	e.secondsSinceLastBlock.WithLabelValues(service).Set(secondsSinceLastBlock)
+	e.stalled.WithLabelValues(service).Set(boolToFloat(stalled))
+	e.logClockSkewSeconds.WithLabelValues(service).Set(clockSkewSeconds)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// serveMetrics 在 addr 上启动一个只暴露 /metrics 的 HTTP server，阻塞直到出错。
+func serveMetrics(addr string, registry *prometheus.Registry) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return http.ListenAndServe(addr, mux)
+}
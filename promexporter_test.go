@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRecordIncrementsBlocksBroadcastTotal(t *testing.T) {
+	e := newPromExporter(prometheus.NewRegistry())
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base, BlockNumber: 1, Miners: 3}, time.Time{})
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base.Add(time.Minute), BlockNumber: 2, Miners: 4}, base)
+
+	got := testutil.ToFloat64(e.blocksBroadcastTotal.WithLabelValues("go-quai-stratum"))
+	if got != 2 {
+		t.Errorf("blocksBroadcastTotal = %v, want 2", got)
+	}
+}
+
+func TestObserveRecordOnlyObservesIntervalFromSecondRecordOnward(t *testing.T) {
+	e := newPromExporter(prometheus.NewRegistry())
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base, BlockNumber: 1}, time.Time{})
+	if got := testutil.CollectAndCount(e.blockIntervalSeconds); got != 0 {
+		t.Fatalf("blockIntervalSeconds sample count after first record = %d, want 0", got)
+	}
+
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base.Add(10 * time.Second), BlockNumber: 2}, base)
+	if got := testutil.CollectAndCount(e.blockIntervalSeconds); got == 0 {
+		t.Fatalf("blockIntervalSeconds sample count after second record = %d, want > 0", got)
+	}
+}
+
+func TestObserveRecordSetsLatestBlockAndMinersConnected(t *testing.T) {
+	e := newPromExporter(prometheus.NewRegistry())
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base, BlockNumber: 10, Miners: 2}, time.Time{})
+	e.observeRecord("go-quai-stratum", blockRecord{Timestamp: base.Add(time.Minute), BlockNumber: 11, Miners: 5}, base)
+
+	if got := testutil.ToFloat64(e.latestBlock.WithLabelValues("go-quai-stratum")); got != 11 {
+		t.Errorf("latestBlock = %v, want 11", got)
+	}
+	if got := testutil.ToFloat64(e.minersConnected.WithLabelValues("go-quai-stratum")); got != 5 {
+		t.Errorf("minersConnected = %v, want 5", got)
+	}
+}
+
+func TestSetCycleResultSetsContinuityAndUpdated(t *testing.T) {
+	e := newPromExporter(prometheus.NewRegistry())
+
+	e.setCycleResult("go-quai-stratum", 1, 0)
+
+	if got := testutil.ToFloat64(e.continuity.WithLabelValues("go-quai-stratum")); got != 1 {
+		t.Errorf("continuity = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(e.updated.WithLabelValues("go-quai-stratum")); got != 0 {
+		t.Errorf("updated = %v, want 0", got)
+	}
+}
+
+func TestSetStallAndSkewSetsGauges(t *testing.T) {
+	e := newPromExporter(prometheus.NewRegistry())
+
+	e.setStallAndSkew("go-quai-stratum", 42.5, true, -0.2)
+
+	if got := testutil.ToFloat64(e.secondsSinceLastBlock.WithLabelValues("go-quai-stratum")); got != 42.5 {
+		t.Errorf("secondsSinceLastBlock = %v, want 42.5", got)
+	}
+	if got := testutil.ToFloat64(e.stalled.WithLabelValues("go-quai-stratum")); got != 1 {
+		t.Errorf("stalled = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(e.logClockSkewSeconds.WithLabelValues("go-quai-stratum")); got != -0.2 {
+		t.Errorf("logClockSkewSeconds = %v, want -0.2", got)
+	}
+}
+
+func TestBoolToFloat(t *testing.T) {
+	if got := boolToFloat(true); got != 1 {
+		t.Errorf("boolToFloat(true) = %v, want 1", got)
+	}
+	if got := boolToFloat(false); got != 0 {
+		t.Errorf("boolToFloat(false) = %v, want 0", got)
+	}
+}
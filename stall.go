@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// stallMultiplier 是判定“停滞”的倍数：当距离上一次观测到的 broadcast 的
+// 间隔超过最近若干次间隔中位数的这么多倍时，认为 service 已经停滞，即使
+// 当前窗口里恰好还留着一段连续的旧区块。
+const stallMultiplier = 3
+
+// maxIntervalSamples 是 stallTracker 用于估计中位数间隔所保留的样本数。
+const maxIntervalSamples = 20
+
+// clockSkewIgnoreThreshold 是吸收 NTP 回拨等噪声用的容忍度：当日志时间戳
+// 比 ingest 时的 wall clock 还晚、但晚得不超过这个量级时，按 0 skew 处理。
+const clockSkewIgnoreThreshold = 10 * time.Millisecond
+
+// stallTracker 跟踪单个 service 距离上一次观测到 broadcast 日志的时间，
+// 并维护一个日志时间戳与 ingest wall clock 之间的滚动时钟偏差估计。
+type stallTracker struct {
+	mu sync.Mutex
+
+	lastLogTimestamp time.Time
+	lastWallClock    time.Time
+	intervals        []time.Duration
+	skewEstimate     time.Duration
+
+	// fallbackThreshold 在样本不足以估计中位数间隔之前使用，避免刚启动时
+	// 误判为 stalled。
+	fallbackThreshold time.Duration
+}
+
+// newStallTracker 创建一个 stallTracker，fallbackThreshold 通常取 service
+// 自身 checkInterval 的若干倍。
+func newStallTracker(fallbackThreshold time.Duration) *stallTracker {
+	return &stallTracker{fallbackThreshold: fallbackThreshold}
+}
+
+// observe 在每条新解析出的日志记录 ingest 时调用，logTimestamp 是日志里
+// 解析出的时间，now 是 ingest 发生时的 wall clock。
+func (st *stallTracker) observe(logTimestamp, now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.lastLogTimestamp.IsZero() {
+		if interval := logTimestamp.Sub(st.lastLogTimestamp); interval > 0 {
+			st.intervals = append(st.intervals, interval)
+			if len(st.intervals) > maxIntervalSamples {
+				st.intervals = st.intervals[len(st.intervals)-maxIntervalSamples:]
+			}
+		}
+	}
+	st.lastLogTimestamp = logTimestamp
+	st.lastWallClock = now
+	st.skewEstimate = clampSkew(now.Sub(logTimestamp))
+}
+
+// snapshot 基于 now 求值当前距离上一次 broadcast 的秒数、是否已停滞，
+// 以及当前的时钟偏差估计（秒）。在还没有任何记录时返回全零值。
+func (st *stallTracker) snapshot(now time.Time) (secondsSinceLastBlock float64, stalled bool, clockSkewSeconds float64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.lastWallClock.IsZero() {
+		return 0, false, 0
+	}
+
+	since := now.Sub(st.lastWallClock)
+	threshold := st.fallbackThreshold
+	if median := medianDuration(st.intervals); median > 0 {
+		threshold = median * stallMultiplier
+	}
+
+	return since.Seconds(), threshold > 0 && since > threshold, st.skewEstimate.Seconds()
+}
+
+// LastSeen 返回上一次 observe 时传入的 wall clock，还没有任何观测时为零值。
+func (st *stallTracker) LastSeen() time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.lastWallClock
+}
+
+// clampSkew 吸收掉幅度小于 clockSkewIgnoreThreshold 的负偏差（日志时间戳
+// 略晚于 ingest 时刻），这类噪声通常来自 NTP 回拨而非真实的时钟漂移。
+func clampSkew(skew time.Duration) time.Duration {
+	if skew < 0 && -skew < clockSkewIgnoreThreshold {
+		return 0
+	}
+	return skew
+}
+
+// medianDuration 返回 durs 的中位数，durs 为空时返回 0。
+func medianDuration(durs []time.Duration) time.Duration {
+	if len(durs) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianDuration(t *testing.T) {
+	cases := []struct {
+		name string
+		durs []time.Duration
+		want time.Duration
+	}{
+		{"empty", nil, 0},
+		{"single", []time.Duration{5 * time.Second}, 5 * time.Second},
+		{"odd", []time.Duration{3 * time.Second, 1 * time.Second, 2 * time.Second}, 2 * time.Second},
+		{"even", []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}, 2500 * time.Millisecond},
+		{"unsorted input not mutated order-dependent", []time.Duration{10 * time.Second, 1 * time.Second}, 5500 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := medianDuration(c.durs); got != c.want {
+				t.Errorf("medianDuration(%v) = %v, want %v", c.durs, got, c.want)
+			}
+		})
+	}
+}
+
+func TestClampSkew(t *testing.T) {
+	cases := []struct {
+		name string
+		skew time.Duration
+		want time.Duration
+	}{
+		{"positive skew passes through", 2 * time.Second, 2 * time.Second},
+		{"small negative skew is absorbed", -5 * time.Millisecond, 0},
+		{"negative skew right at the edge is absorbed", -(clockSkewIgnoreThreshold - time.Microsecond), 0},
+		{"large negative skew passes through", -500 * time.Millisecond, -500 * time.Millisecond},
+		{"zero skew stays zero", 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := clampSkew(c.skew); got != c.want {
+				t.Errorf("clampSkew(%v) = %v, want %v", c.skew, got, c.want)
+			}
+		})
+	}
+}
+
+func TestStallTrackerSnapshotBeforeAnyObservation(t *testing.T) {
+	st := newStallTracker(time.Minute)
+
+	secondsSinceLastBlock, stalled, skew := st.snapshot(time.Now())
+	if secondsSinceLastBlock != 0 || stalled || skew != 0 {
+		t.Fatalf("snapshot before any observe() = (%v, %v, %v), want all zero/false", secondsSinceLastBlock, stalled, skew)
+	}
+}
+
+func TestStallTrackerFallbackThresholdBeforeEnoughSamples(t *testing.T) {
+	st := newStallTracker(10 * time.Second)
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st.observe(base, base)
+
+	// 只有一个样本，还不足以估计 median，应该退回 fallbackThreshold。
+	_, stalled, _ := st.snapshot(base.Add(5 * time.Second))
+	if stalled {
+		t.Fatalf("expected not stalled within fallbackThreshold, got stalled")
+	}
+	_, stalled, _ = st.snapshot(base.Add(20 * time.Second))
+	if !stalled {
+		t.Fatalf("expected stalled beyond fallbackThreshold, got not stalled")
+	}
+}